@@ -0,0 +1,203 @@
+// Package env provides a first-class representation of a micro
+// environment: the server it points at, the namespace it operates in,
+// and the auth provider and credentials used to talk to it. It
+// replaces the ad-hoc micro.auth.[envName].* userconfig keys with a
+// single self-contained record per environment.
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"micro.dev/v4/service/logger"
+	uconf "micro.dev/v4/util/config"
+)
+
+// Environment is a self-contained micro client context: where to
+// connect, which namespace to operate in, which auth provider issued
+// its credentials, and the credentials themselves.
+type Environment struct {
+	// Name of the environment, e.g. "prod" or "local"
+	Name string
+	// URL is the network/proxy address the CLI connects to
+	URL string
+	// Namespace the environment operates in
+	Namespace string
+
+	// Provider is the name of the OAuth provider config used for
+	// `micro login`, e.g. "auth0". Empty if the environment uses
+	// micro's own auth issuer.
+	Provider      string
+	Issuer        string
+	DeviceAuthURL string
+	TokenURL      string
+	ClientID      string
+	Audience      string
+	Scopes        []string
+	PublicKey     string
+
+	// AccessToken, RefreshToken and ExpiresAt are the current micro
+	// credentials for this environment.
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+var (
+	once sync.Once
+	mtx  sync.RWMutex
+
+	envs    map[string]*Environment
+	current string
+)
+
+// readEnvsFromDisk loads the envs map out of the user config file,
+// resetting to empty (and logging) if the stored value is corrupt
+// rather than silently losing track of the caller's own writes.
+func readEnvsFromDisk() map[string]*Environment {
+	out := map[string]*Environment{}
+
+	if raw := uconf.Get("micro", "envs").Bytes(); len(raw) > 0 {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			logger.Warnf("env: failed to parse stored environments, resetting: %v", err)
+			return map[string]*Environment{}
+		}
+	}
+
+	return out
+}
+
+// load reads the envs and current env name from the user config file.
+// It only runs once per process, on first use; a process which never
+// reads or writes an environment never pays for it.
+func load() {
+	once.Do(func() {
+		envs = readEnvsFromDisk()
+		current = uconf.Get("micro", "env").String("")
+	})
+}
+
+// Get returns the named environment. If name has no stored record yet
+// (e.g. the user has never run `micro login` or `micro env add` for
+// it), a zero-value Environment scoped to name is returned rather than
+// an error, matching the old behaviour of the ad-hoc config keys
+// simply defaulting to empty strings.
+func Get(name string) (*Environment, error) {
+	load()
+
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	if e, ok := envs[name]; ok {
+		return e, nil
+	}
+	return &Environment{Name: name}, nil
+}
+
+// Current returns the name of the currently active environment.
+func Current() string {
+	load()
+
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return current
+}
+
+// List returns every known environment, sorted by name for
+// deterministic output.
+func List() ([]*Environment, error) {
+	load()
+
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	ret := make([]*Environment, 0, len(envs))
+	for _, e := range envs {
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Save persists env, replacing any existing record of the same name.
+// The on-disk envs map is re-read under the file lock before merging
+// so two CLI invocations saving different environments concurrently
+// don't clobber each other.
+func Save(e *Environment) error {
+	load()
+
+	return withFileLock(func() error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		envs = readEnvsFromDisk()
+		envs[e.Name] = e
+		return uconf.Set(envs, "micro", "envs")
+	})
+}
+
+// SetCurrent swaps the active environment, atomically switching the
+// CLI's client context to env. The environment must already exist.
+func SetCurrent(name string) error {
+	load()
+
+	return withFileLock(func() error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		envs = readEnvsFromDisk()
+		if _, ok := envs[name]; !ok {
+			return fmt.Errorf("environment %q not found", name)
+		}
+		current = name
+		return uconf.Set(name, "micro", "env")
+	})
+}
+
+// withFileLock serializes writes to the user config file with an
+// flock-based lock file, so two concurrent CLI invocations saving an
+// environment can't race and corrupt config.json.
+func withFileLock(fn func() error) error {
+	dir := configDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "env.lock")
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+// configDir returns the directory the lock file lives in, honouring
+// the MICRO_CONFIG_FILE environment variable (the one behind the -c
+// flag) so the lock sits next to that config file. A path given with
+// -c on the command line does not set the variable and is not seen
+// here. Falls back to ~/.micro, same default as the config loader.
+func configDir() string {
+	if cf := os.Getenv("MICRO_CONFIG_FILE"); len(cf) > 0 {
+		return filepath.Dir(cf)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, ".micro")
+}
@@ -0,0 +1,27 @@
+package env
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDirHonoursMicroConfigFile(t *testing.T) {
+	t.Setenv("MICRO_CONFIG_FILE", "/tmp/somewhere/else/config.json")
+
+	got := configDir()
+	want := "/tmp/somewhere/else"
+	if got != want {
+		t.Fatalf("configDir() = %q, want %q", got, want)
+	}
+}
+
+func TestConfigDirFallsBackToHomeMicro(t *testing.T) {
+	t.Setenv("MICRO_CONFIG_FILE", "")
+	t.Setenv("HOME", "/tmp/fakehome")
+
+	got := configDir()
+	want := filepath.Join("/tmp/fakehome", ".micro")
+	if got != want {
+		t.Fatalf("configDir() = %q, want %q", got, want)
+	}
+}
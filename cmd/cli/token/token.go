@@ -0,0 +1,55 @@
+// Package token reads and writes the micro access/refresh token for the
+// environment selected on a cli.Context. It is a thin compatibility
+// wrapper around cmd/cli/env: the token lives on the current
+// Environment record, so this package has exactly one underlying
+// store rather than a second one to keep in sync.
+package token
+
+import (
+	"github.com/urfave/cli/v2"
+	cliEnv "micro.dev/v4/cmd/cli/env"
+	"micro.dev/v4/cmd/cli/util"
+	"micro.dev/v4/service/auth"
+)
+
+// Get returns the current token for the environment selected on ctx.
+func Get(ctx *cli.Context) (*auth.Token, error) {
+	ev, err := util.GetEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e, err := cliEnv.Get(ev.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.Token{
+		AccessToken:  e.AccessToken,
+		RefreshToken: e.RefreshToken,
+		Expiry:       e.ExpiresAt,
+	}, nil
+}
+
+// Save persists tok against the environment selected on ctx.
+func Save(ctx *cli.Context, tok *auth.Token) error {
+	ev, err := util.GetEnv(ctx)
+	if err != nil {
+		return err
+	}
+	e, err := cliEnv.Get(ev.Name)
+	if err != nil {
+		return err
+	}
+
+	Apply(e, tok)
+	return cliEnv.Save(e)
+}
+
+// Apply copies tok's fields onto e without saving it, for callers (like
+// `micro login`'s device flow) that need to mutate other Environment
+// fields, e.g. Provider, in the same save.
+func Apply(e *cliEnv.Environment, tok *auth.Token) {
+	e.AccessToken = tok.AccessToken
+	e.RefreshToken = tok.RefreshToken
+	e.ExpiresAt = tok.Expiry
+}
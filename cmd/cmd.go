@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/urfave/cli/v2"
+	cliEnv "micro.dev/v4/cmd/cli/env"
 	clitoken "micro.dev/v4/cmd/cli/token"
 	"micro.dev/v4/cmd/cli/util"
 	"micro.dev/v4/service/auth"
@@ -21,6 +22,7 @@ import (
 	storeConf "micro.dev/v4/service/config/store"
 	"micro.dev/v4/service/errors"
 	"micro.dev/v4/service/logger"
+	"micro.dev/v4/service/namespace"
 	"micro.dev/v4/service/network"
 	"micro.dev/v4/service/profile"
 	"micro.dev/v4/service/registry"
@@ -29,7 +31,6 @@ import (
 	"micro.dev/v4/service/store"
 	uconf "micro.dev/v4/util/config"
 	"micro.dev/v4/util/helper"
-	"micro.dev/v4/util/namespace"
 	"micro.dev/v4/util/wrapper"
 )
 
@@ -117,6 +118,17 @@ var (
 			Usage:   "Service network address",
 			EnvVars: []string{"MICRO_SERVICE_NETWORK"},
 		},
+		&cli.DurationFlag{
+			Name:    "init-retry-timeout",
+			Usage:   "How long to keep retrying broker/registry/runtime/store/auth initialization before giving up. Defaults to 0, which fails immediately as before",
+			EnvVars: []string{"MICRO_INIT_RETRY_TIMEOUT"},
+		},
+		&cli.DurationFlag{
+			Name:    "init-retry-sleep",
+			Usage:   "How long to sleep between initialization retries",
+			EnvVars: []string{"MICRO_INIT_RETRY_SLEEP"},
+			Value:   time.Second * 2,
+		},
 	}
 )
 
@@ -140,17 +152,16 @@ func upcaseInitial(str string) string {
 	return ""
 }
 
-// setupAuthForCLI handles exchanging refresh tokens to access tokens
-// The structure of the local micro userconfig file is the following:
-// micro.auth.[envName].token: temporary access token
-// micro.auth.[envName].refresh-token: long lived refresh token
-// micro.auth.[envName].expiry: expiration time of the access token, seconds since Unix epoch.
+// setupAuthForCLI handles exchanging refresh tokens to access tokens.
+// Credentials are read from and written back to the current
+// Environment record (see cmd/cli/env) via clitoken, which replaces the
+// old ad-hoc micro.auth.[envName].* userconfig keys.
 func setupAuthForCLI(ctx *cli.Context) error {
-	env, err := util.GetEnv(ctx)
+	ev, err := util.GetEnv(ctx)
 	if err != nil {
 		return err
 	}
-	ns, err := namespace.Get(env.Name)
+	issuer, err := authIssuer(ev.Name)
 	if err != nil {
 		return err
 	}
@@ -169,7 +180,7 @@ func setupAuthForCLI(ctx *cli.Context) error {
 	if time.Now().Before(tok.Expiry.Add(time.Minute * -1)) {
 		auth.DefaultAuth.Init(
 			auth.ClientToken(tok),
-			auth.Issuer(ns),
+			auth.Issuer(issuer),
 		)
 		return nil
 	}
@@ -177,48 +188,66 @@ func setupAuthForCLI(ctx *cli.Context) error {
 	// Get new access token from refresh token if it's close to expiry
 	tok, err = auth.Token(
 		auth.WithToken(tok.RefreshToken),
-		auth.WithTokenIssuer(ns),
+		auth.WithTokenIssuer(issuer),
 		auth.WithExpiry(time.Hour*24),
 	)
 	if err != nil {
 		return nil
 	}
 
-	// Save the token to user config file
+	// Save the token back to the environment record
 	auth.DefaultAuth.Init(
 		auth.ClientToken(tok),
-		auth.Issuer(ns),
+		auth.Issuer(issuer),
 	)
+
 	return clitoken.Save(ctx, tok)
 }
 
-// setupAuthForService generates auth credentials for the service
-func setupAuthForService() error {
-	opts := auth.DefaultAuth.Options()
-
-	// extract the account creds from options, these can be set by flags
-	accID := opts.ID
-	accSecret := opts.Secret
-
-	// if no credentials were provided, self generate an account
-	if len(accID) == 0 || len(accSecret) == 0 {
-		opts := []auth.GenerateOption{
-			auth.WithType("service"),
-			auth.WithScopes("service"),
-		}
+// authIssuer resolves the auth token issuer for env: the Environment's
+// own Issuer field if one was configured (e.g. via `micro env add
+// --issuer=...` for an externally-provisioned OAuth provider), falling
+// back to the env's namespace for plain micro-issued tokens, as before
+// the Environment abstraction existed.
+func authIssuer(envName string) (string, error) {
+	e, err := cliEnv.Get(envName)
+	if err != nil {
+		return "", err
+	}
+	if len(e.Issuer) > 0 {
+		return e.Issuer, nil
+	}
+	return namespace.Get(envName)
+}
 
-		acc, err := auth.Generate(uuid.New().String(), opts...)
-		if err != nil {
-			return err
-		}
-		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-			logger.Debugf("Auth [%v] Generated an auth account", auth.DefaultAuth.String())
-		}
+// serviceAccountCredentials returns the static client_id/client_secret from
+// auth options if they were set via flags, otherwise self-generates a new
+// service account. Called once per process, outside of any retry loop: if
+// it were called again on every retry it would mint a new orphaned service
+// account on each failed attempt.
+func serviceAccountCredentials() (id, secret string, err error) {
+	opts := auth.DefaultAuth.Options()
+	if len(opts.ID) > 0 && len(opts.Secret) > 0 {
+		return opts.ID, opts.Secret, nil
+	}
 
-		accID = acc.ID
-		accSecret = acc.Secret
+	acc, err := auth.Generate(uuid.New().String(),
+		auth.WithType("service"),
+		auth.WithScopes("service"),
+	)
+	if err != nil {
+		return "", "", err
+	}
+	if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+		logger.Debugf("Auth [%v] Generated an auth account", auth.DefaultAuth.String())
 	}
 
+	return acc.ID, acc.Secret, nil
+}
+
+// setupAuthForService exchanges accID/accSecret for a token and sets it as
+// the default auth credentials.
+func setupAuthForService(accID, accSecret string) error {
 	// generate the first token
 	token, err := auth.Token(
 		auth.WithCredentials(accID, accSecret),
@@ -282,6 +311,43 @@ func refreshAuthToken() {
 	}
 }
 
+// retryInit calls fn, retrying on error until elapsed+sleep would exceed
+// timeout, at which point the last error is returned. A timeout of 0
+// disables retrying, so fn is attempted exactly once; this keeps the
+// default behaviour of failing fast on the first error.
+func retryInit(name string, timeout, sleep time.Duration, fn func() error) error {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("%v: Attempt #%d", name, attempt)
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if time.Since(start)+sleep > timeout {
+			return err
+		}
+
+		logger.Warnf("%v: attempt #%d failed: %v, retrying in %v", name, attempt, err, sleep)
+		time.Sleep(sleep)
+	}
+}
+
+// Retry overrides the init retry policy programmatically, taking
+// precedence over the --init-retry-timeout and --init-retry-sleep flags.
+// A zero value leaves the corresponding flag in effect. Useful for embedders of cmd.New that want retrying infrastructure
+// initialization without relying on CLI flags.
+func Retry(timeout, sleep time.Duration) Option {
+	return func(o *Options) {
+		o.RetryTimeout = timeout
+		o.RetrySleep = sleep
+	}
+}
+
 func action(c *cli.Context) error {
 	if c.Args().Len() == 0 {
 		return helper.MissingCommand(c)
@@ -436,13 +502,34 @@ func (c *command) Before(ctx *cli.Context) error {
 	// setup auth
 	auth.DefaultAuth.Init(authOpts...)
 
+	// resolve the retry policy: flags by default, overridden by whatever
+	// was passed to cmd.New via the Retry option so embedders can set it
+	// programmatically.
+	retryTimeout := ctx.Duration("init-retry-timeout")
+	retrySleep := ctx.Duration("init-retry-sleep")
+	if c.opts.RetryTimeout > 0 {
+		retryTimeout = c.opts.RetryTimeout
+	}
+	if c.opts.RetrySleep > 0 {
+		retrySleep = c.opts.RetrySleep
+	}
+
 	// setup auth credentials, use local credentials for the CLI and injected creds
 	// for the service.
 	var err error
 	if c.service {
-		err = setupAuthForService()
+		// generate (or read) the service account once, outside the retry
+		// loop, so a transient token-exchange failure retries the
+		// exchange rather than minting a new account each attempt
+		accID, accSecret, acctErr := serviceAccountCredentials()
+		if acctErr != nil {
+			logger.Fatalf("Error setting up auth: %v", acctErr)
+		}
+		err = retryInit("auth", retryTimeout, retrySleep, func() error {
+			return setupAuthForService(accID, accSecret)
+		})
 	} else {
-		err = setupAuthForCLI(ctx)
+		err = retryInit("auth", retryTimeout, retrySleep, func() error { return setupAuthForCLI(ctx) })
 	}
 	if err != nil {
 		logger.Fatalf("Error setting up auth: %v", err)
@@ -456,23 +543,29 @@ func (c *command) Before(ctx *cli.Context) error {
 	// setup registry
 	registryOpts := []registry.Option{}
 
-	if err := registry.DefaultRegistry.Init(registryOpts...); err != nil {
+	if err := retryInit("registry", retryTimeout, retrySleep, func() error {
+		return registry.DefaultRegistry.Init(registryOpts...)
+	}); err != nil {
 		logger.Fatalf("Error configuring registry: %v", err)
 	}
 
 	// Setup broker options.
 	brokerOpts := []broker.Option{}
 
-	if err := broker.DefaultBroker.Init(brokerOpts...); err != nil {
-		logger.Fatalf("Error configuring broker: %v", err)
-	}
-	if err := broker.DefaultBroker.Connect(); err != nil {
+	if err := retryInit("broker", retryTimeout, retrySleep, func() error {
+		// re-run Init on every attempt so a partially constructed client
+		// from a previous failed attempt is discarded
+		if err := broker.DefaultBroker.Init(brokerOpts...); err != nil {
+			return err
+		}
+		return broker.DefaultBroker.Connect()
+	}); err != nil {
 		logger.Fatalf("Error connecting to broker: %v", err)
 	}
 
 	// Setup runtime. This is a temporary fix to trigger the runtime to recreate
 	// its client now the client has been replaced with a wrapped one.
-	if err := runtime.DefaultRuntime.Init(); err != nil {
+	if err := retryInit("runtime", retryTimeout, retrySleep, runtime.DefaultRuntime.Init); err != nil {
 		logger.Fatalf("Error configuring runtime: %v", err)
 	}
 
@@ -487,7 +580,9 @@ func (c *command) Before(ctx *cli.Context) error {
 		storeOpts = append(storeOpts, store.Table(ctx.String("name")))
 	}
 
-	if err := store.DefaultStore.Init(storeOpts...); err != nil {
+	if err := retryInit("store", retryTimeout, retrySleep, func() error {
+		return store.DefaultStore.Init(storeOpts...)
+	}); err != nil {
 		logger.Fatalf("Error configuring store: %v", err)
 	}
 
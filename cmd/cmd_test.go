@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryInitZeroTimeoutFailsFast(t *testing.T) {
+	calls := 0
+	wantErr := errors.New("boom")
+
+	err := retryInit("test", 0, time.Millisecond, func() error {
+		calls++
+		return wantErr
+	})
+
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly 1 attempt with a zero timeout, got %d", calls)
+	}
+}
+
+func TestRetryInitRetriesUntilTimeoutExceeded(t *testing.T) {
+	calls := 0
+	wantErr := errors.New("still failing")
+
+	err := retryInit("test", 25*time.Millisecond, 10*time.Millisecond, func() error {
+		calls++
+		return wantErr
+	})
+
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+	if calls < 2 {
+		t.Fatalf("expected more than one attempt before giving up, got %d", calls)
+	}
+}
+
+func TestRetryInitSucceedsAfterTransientError(t *testing.T) {
+	calls := 0
+
+	err := retryInit("test", time.Second, time.Millisecond, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected eventual success, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
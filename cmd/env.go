@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	cliEnv "micro.dev/v4/cmd/cli/env"
+	"micro.dev/v4/cmd/cli/util"
+)
+
+func init() {
+	Register(envCommand())
+}
+
+func envCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "env",
+		Usage: "Add, list or switch between micro environments",
+		Subcommands: []*cli.Command{
+			envAddCommand(),
+			envSetCommand(),
+			envListCommand(),
+		},
+		Action: func(ctx *cli.Context) error {
+			return envList(ctx)
+		},
+	}
+}
+
+func envAddCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "add",
+		Usage:     "Add a new environment",
+		ArgsUsage: "NAME",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "url",
+				Usage: "Network/proxy address the environment connects to",
+			},
+			&cli.StringFlag{
+				Name:  "namespace",
+				Usage: "Namespace the environment operates in",
+				Value: "micro",
+			},
+			&cli.StringFlag{
+				Name:  "provider",
+				Usage: "Name of the OAuth device flow provider used for `micro login`, e.g. auth0, okta, google, keycloak",
+			},
+			&cli.StringFlag{
+				Name:  "issuer",
+				Usage: "Auth token issuer. Defaults to the environment's namespace if unset",
+			},
+			&cli.StringFlag{
+				Name:  "device-auth-url",
+				Usage: "Provider's /oauth/device/code endpoint",
+			},
+			&cli.StringFlag{
+				Name:  "token-url",
+				Usage: "Provider's /oauth/token endpoint",
+			},
+			&cli.StringFlag{
+				Name:  "client-id",
+				Usage: "OAuth client ID registered with the provider",
+			},
+			&cli.StringFlag{
+				Name:  "audience",
+				Usage: "OAuth audience to request",
+			},
+			&cli.StringFlag{
+				Name:  "scopes",
+				Usage: "Comma separated list of OAuth scopes to request",
+			},
+			&cli.StringFlag{
+				Name:  "public-key",
+				Usage: "Public key used to verify tokens issued by the provider (base64 encoded PEM)",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			name := ctx.Args().First()
+			if len(name) == 0 {
+				return util.CliError(fmt.Errorf("specify the environment name, e.g. `micro env add prod --url=...`"))
+			}
+
+			// get-then-mutate-then-save, same as deviceLogin: env add is
+			// also used to update an existing record's provider config,
+			// and a fresh Environment{} would wipe out any credentials
+			// the user already logged in with.
+			e, err := cliEnv.Get(name)
+			if err != nil {
+				return util.CliError(err)
+			}
+
+			if ctx.IsSet("url") {
+				e.URL = ctx.String("url")
+			}
+			if ctx.IsSet("namespace") || len(e.Namespace) == 0 {
+				// apply the --namespace default ("micro") on first add,
+				// but don't clobber an existing custom namespace on a
+				// later `env add` that's only updating other fields
+				e.Namespace = ctx.String("namespace")
+			}
+			if ctx.IsSet("provider") {
+				e.Provider = ctx.String("provider")
+			}
+			if ctx.IsSet("issuer") {
+				e.Issuer = ctx.String("issuer")
+			}
+			if ctx.IsSet("device-auth-url") {
+				e.DeviceAuthURL = ctx.String("device-auth-url")
+			}
+			if ctx.IsSet("token-url") {
+				e.TokenURL = ctx.String("token-url")
+			}
+			if ctx.IsSet("client-id") {
+				e.ClientID = ctx.String("client-id")
+			}
+			if ctx.IsSet("audience") {
+				e.Audience = ctx.String("audience")
+			}
+			if ctx.IsSet("public-key") {
+				e.PublicKey = ctx.String("public-key")
+			}
+			if scopes := ctx.String("scopes"); len(scopes) > 0 {
+				e.Scopes = strings.Split(scopes, ",")
+			}
+
+			if err := cliEnv.Save(e); err != nil {
+				return util.CliError(err)
+			}
+
+			fmt.Printf("Added environment %v\n", name)
+			return nil
+		},
+	}
+}
+
+func envSetCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "set",
+		Usage:     "Set the current environment",
+		ArgsUsage: "NAME",
+		Action: func(ctx *cli.Context) error {
+			name := ctx.Args().First()
+			if len(name) == 0 {
+				return util.CliError(fmt.Errorf("specify the environment name, e.g. `micro env set prod`"))
+			}
+
+			if err := cliEnv.SetCurrent(name); err != nil {
+				return util.CliError(err)
+			}
+
+			fmt.Printf("Set current environment to %v\n", name)
+			return nil
+		},
+	}
+}
+
+func envListCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List known environments",
+		Action: func(ctx *cli.Context) error {
+			return envList(ctx)
+		},
+	}
+}
+
+// envList prints every known environment, marking the current one.
+func envList(ctx *cli.Context) error {
+	envs, err := cliEnv.List()
+	if err != nil {
+		return util.CliError(err)
+	}
+
+	current := cliEnv.Current()
+	for _, e := range envs {
+		marker := "  "
+		if e.Name == current {
+			marker = "* "
+		}
+		fmt.Printf("%v%v\t%v\n", marker, e.Name, e.URL)
+	}
+	return nil
+}
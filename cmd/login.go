@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	cliEnv "micro.dev/v4/cmd/cli/env"
+	clitoken "micro.dev/v4/cmd/cli/token"
+	"micro.dev/v4/cmd/cli/util"
+	"micro.dev/v4/service/auth"
+	"micro.dev/v4/service/logger"
+	"micro.dev/v4/service/namespace"
+)
+
+func init() {
+	Register(loginCommand())
+}
+
+// oauthProvider describes the device-flow endpoints and client
+// configuration for a single external identity provider. It is
+// sourced from the current Environment's own provider fields (see
+// cmd/cli/env), so different envs can front different IdPs.
+type oauthProvider struct {
+	Name          string
+	DeviceAuthURL string
+	TokenURL      string
+	ClientID      string
+	Audience      string
+	Scope         string
+}
+
+// loadOAuthProvider builds the device-flow provider config out of the
+// given environment's stored provider fields.
+func loadOAuthProvider(e *cliEnv.Environment, provider string) (*oauthProvider, error) {
+	p := &oauthProvider{
+		Name:          provider,
+		DeviceAuthURL: e.DeviceAuthURL,
+		TokenURL:      e.TokenURL,
+		ClientID:      e.ClientID,
+		Audience:      e.Audience,
+		Scope:         strings.Join(e.Scopes, " "),
+	}
+	if len(p.Scope) == 0 {
+		p.Scope = "openid profile email offline_access"
+	}
+
+	if len(p.DeviceAuthURL) == 0 || len(p.TokenURL) == 0 || len(p.ClientID) == 0 {
+		return nil, fmt.Errorf("no device flow config found for provider %q in env %q, set it with `micro env add`", provider, e.Name)
+	}
+
+	return p, nil
+}
+
+// deviceCodeResponse is the response of a provider's
+// /oauth/device/code endpoint.
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// deviceTokenResponse is the response of a provider's /oauth/token
+// endpoint, either a successful token grant or an OAuth error.
+type deviceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+}
+
+// requestDeviceCode starts the device authorization flow by asking
+// the provider for a device_code/user_code pair.
+func requestDeviceCode(p *oauthProvider) (*deviceCodeResponse, error) {
+	form := url.Values{
+		"client_id": {p.ClientID},
+		"scope":     {p.Scope},
+	}
+	if len(p.Audience) > 0 {
+		form.Set("audience", p.Audience)
+	}
+
+	rsp, err := http.PostForm(p.DeviceAuthURL, form)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	var dc deviceCodeResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&dc); err != nil {
+		return nil, err
+	}
+	if len(dc.DeviceCode) == 0 {
+		return nil, fmt.Errorf("provider %q did not return a device code", p.Name)
+	}
+
+	return &dc, nil
+}
+
+// pollDeviceToken polls the provider's token endpoint until the user
+// has approved the login, the code expires, or access is denied.
+func pollDeviceToken(p *oauthProvider, dc *deviceCodeResponse) (*deviceTokenResponse, error) {
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(dc.ExpiresIn) * time.Second)
+
+	form := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {dc.DeviceCode},
+		"client_id":   {p.ClientID},
+	}
+
+	for {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("device code expired, please try again")
+		}
+
+		time.Sleep(interval)
+
+		rsp, err := http.PostForm(p.TokenURL, form)
+		if err != nil {
+			return nil, err
+		}
+
+		var tr deviceTokenResponse
+		err = json.NewDecoder(rsp.Body).Decode(&tr)
+		rsp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tr.Error {
+		case "":
+			return &tr, nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		case "expired_token":
+			return nil, fmt.Errorf("device code expired, please try again")
+		case "access_denied":
+			return nil, fmt.Errorf("access denied")
+		default:
+			return nil, fmt.Errorf("provider %q returned error: %v", p.Name, tr.Error)
+		}
+	}
+}
+
+// deviceLogin runs the full device authorization flow against the
+// provider configured for the current environment and stores the
+// resulting micro token, scoped to that environment.
+func deviceLogin(ctx *cli.Context) error {
+	provider := ctx.String("provider")
+	if len(provider) == 0 {
+		return fmt.Errorf("missing required flag --provider")
+	}
+
+	ev, err := util.GetEnv(ctx)
+	if err != nil {
+		return err
+	}
+	e, err := cliEnv.Get(ev.Name)
+	if err != nil {
+		return err
+	}
+
+	p, err := loadOAuthProvider(e, provider)
+	if err != nil {
+		return err
+	}
+
+	dc, err := requestDeviceCode(p)
+	if err != nil {
+		return fmt.Errorf("error requesting device code: %v", err)
+	}
+
+	verificationURI := dc.VerificationURIComplete
+	if len(verificationURI) == 0 {
+		verificationURI = dc.VerificationURI
+	}
+	fmt.Printf("To login, visit %v and enter code: %v\n", verificationURI, dc.UserCode)
+
+	tr, err := pollDeviceToken(p, dc)
+	if err != nil {
+		return err
+	}
+
+	// prefer the id_token for verification, since device/OIDC exchanges
+	// assert identity there; fall back to the access token for providers
+	// that don't issue one (e.g. the openid scope wasn't granted)
+	providerToken := tr.IDToken
+	if len(providerToken) == 0 {
+		providerToken = tr.AccessToken
+	}
+
+	issuer := e.Issuer
+	if len(issuer) == 0 {
+		issuer, err = namespace.Get(e.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// exchange the provider token for a micro token scoped to this env
+	tok, err := auth.Token(
+		auth.WithToken(providerToken),
+		auth.WithTokenIssuer(issuer),
+		auth.WithExpiry(time.Hour*24),
+	)
+	if err != nil {
+		return fmt.Errorf("error exchanging provider token: %v", err)
+	}
+
+	e.Provider = provider
+	clitoken.Apply(e, tok)
+	if err := cliEnv.Save(e); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully logged into %v via %v\n", e.Name, provider)
+	return nil
+}
+
+func loginCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "login",
+		Usage: "Login to a micro environment via an external OAuth provider",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "provider",
+				Usage: "Name of the configured OAuth device flow provider to use, e.g. auth0, okta, google, keycloak",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if len(ctx.String("provider")) == 0 {
+				return util.CliError(fmt.Errorf("the `micro login` command requires --provider to be set"))
+			}
+			if err := deviceLogin(ctx); err != nil {
+				if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+					logger.Debugf("Login error: %v", err)
+				}
+				return util.CliError(err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cliEnv "micro.dev/v4/cmd/cli/env"
+)
+
+func TestLoadOAuthProviderDefaultsScope(t *testing.T) {
+	e := &cliEnv.Environment{
+		Name:          "prod",
+		DeviceAuthURL: "https://idp.example.com/oauth/device/code",
+		TokenURL:      "https://idp.example.com/oauth/token",
+		ClientID:      "abc123",
+	}
+
+	p, err := loadOAuthProvider(e, "auth0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "openid profile email offline_access"; p.Scope != want {
+		t.Fatalf("Scope = %q, want %q", p.Scope, want)
+	}
+}
+
+func TestLoadOAuthProviderMissingConfig(t *testing.T) {
+	e := &cliEnv.Environment{Name: "prod"}
+
+	if _, err := loadOAuthProvider(e, "auth0"); err == nil {
+		t.Fatal("expected an error when no device flow config is set on the environment")
+	}
+}
+
+// tokenResponses serves a fixed sequence of /oauth/token responses, one
+// per request, replaying the last one once the sequence is exhausted.
+func tokenResponses(t *testing.T, responses ...deviceTokenResponse) *httptest.Server {
+	t.Helper()
+
+	var n int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt32(&n, 1) - 1
+		if int(i) >= len(responses) {
+			i = int32(len(responses) - 1)
+		}
+		if err := json.NewEncoder(w).Encode(responses[i]); err != nil {
+			t.Fatalf("encode response: %v", err)
+		}
+	}))
+}
+
+func TestPollDeviceTokenSucceedsAfterPending(t *testing.T) {
+	srv := tokenResponses(t,
+		deviceTokenResponse{Error: "authorization_pending"},
+		deviceTokenResponse{AccessToken: "at", IDToken: "it"},
+	)
+	defer srv.Close()
+
+	p := &oauthProvider{Name: "auth0", TokenURL: srv.URL, ClientID: "client"}
+	dc := &deviceCodeResponse{DeviceCode: "code", Interval: 1, ExpiresIn: 300}
+
+	tr, err := pollDeviceToken(p, dc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tr.IDToken != "it" {
+		t.Fatalf("IDToken = %q, want %q", tr.IDToken, "it")
+	}
+}
+
+func TestPollDeviceTokenBacksOffOnSlowDown(t *testing.T) {
+	srv := tokenResponses(t,
+		deviceTokenResponse{Error: "slow_down"},
+		deviceTokenResponse{AccessToken: "at"},
+	)
+	defer srv.Close()
+
+	p := &oauthProvider{Name: "auth0", TokenURL: srv.URL, ClientID: "client"}
+	dc := &deviceCodeResponse{DeviceCode: "code", Interval: 1, ExpiresIn: 300}
+
+	start := time.Now()
+	tr, err := pollDeviceToken(p, dc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tr.AccessToken != "at" {
+		t.Fatalf("AccessToken = %q, want %q", tr.AccessToken, "at")
+	}
+	// 1s before the first poll, then 1s+5s after slow_down
+	if elapsed := time.Since(start); elapsed < 7*time.Second {
+		t.Fatalf("elapsed = %v, want at least 7s after slow_down", elapsed)
+	}
+}
+
+func TestPollDeviceTokenExpiredToken(t *testing.T) {
+	srv := tokenResponses(t, deviceTokenResponse{Error: "expired_token"})
+	defer srv.Close()
+
+	p := &oauthProvider{Name: "auth0", TokenURL: srv.URL, ClientID: "client"}
+	dc := &deviceCodeResponse{DeviceCode: "code", Interval: 1, ExpiresIn: 300}
+
+	if _, err := pollDeviceToken(p, dc); err == nil {
+		t.Fatal("expected an error when the provider reports expired_token")
+	}
+}
+
+func TestPollDeviceTokenAccessDenied(t *testing.T) {
+	srv := tokenResponses(t, deviceTokenResponse{Error: "access_denied"})
+	defer srv.Close()
+
+	p := &oauthProvider{Name: "auth0", TokenURL: srv.URL, ClientID: "client"}
+	dc := &deviceCodeResponse{DeviceCode: "code", Interval: 1, ExpiresIn: 300}
+
+	if _, err := pollDeviceToken(p, dc); err == nil {
+		t.Fatal("expected an error when the provider reports access_denied")
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Options are the options for configuring the command, mirroring what's
+// passed to cmd.New. Embedders set them by passing Option funcs, such as
+// Retry in cmd.go, rather than setting fields directly.
+type Options struct {
+	// Context to pass to the Before/After hooks generated by
+	// beforeFromContext/serviceFromContext
+	Context context.Context
+
+	// Action to run instead of the default service lookup action
+	Action cli.ActionFunc
+
+	// Flags to add in addition to the default set
+	Flags []cli.Flag
+
+	// RetryTimeout and RetrySleep override the --init-retry-timeout and
+	// --init-retry-sleep flags for infrastructure initialization in
+	// command.Before. Zero means "use whatever the flags resolved to".
+	// Set via the Retry option.
+	RetryTimeout time.Duration
+	RetrySleep   time.Duration
+}
+
+// Option configures Options
+type Option func(o *Options)